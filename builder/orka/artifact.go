@@ -0,0 +1,44 @@
+package orka
+
+import "fmt"
+
+// BuilderId is the unique id for this builder, used to identify artifacts
+// and state belonging to it.
+const BuilderId = "packer.builder.orka"
+
+// Artifact represents an Orka image created by the builder.
+type Artifact struct {
+	// ImageName is the name of the image produced by the build.
+	ImageName string
+
+	// BuilderIdValue is the unique id of the builder that created this artifact.
+	BuilderIdValue string
+
+	// StateData holds arbitrary state produced by the builder's steps,
+	// surfaced to post-processors via Artifact.State.
+	StateData map[string]interface{}
+}
+
+func (a *Artifact) BuilderId() string {
+	return a.BuilderIdValue
+}
+
+func (a *Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return a.ImageName
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Orka image: %s", a.ImageName)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.StateData[name]
+}
+
+func (a *Artifact) Destroy() error {
+	return nil
+}
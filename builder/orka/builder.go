@@ -0,0 +1,82 @@
+package orka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// Builder implements packer.Builder for the Orka platform.
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	c, warnings, err := NewConfig(raws...)
+	if err != nil {
+		return nil, warnings, err
+	}
+	b.config = *c
+
+	return nil, warnings, nil
+}
+
+func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", &b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&stepOrkaCreate{},
+		&communicator.StepConnectSSH{
+			Config:    &b.config.Comm,
+			Host:      communicator.CommHost(b.config.Comm.SSHHost, "ssh_host"),
+			SSHConfig: b.config.Comm.SSHConfigFunc(),
+		},
+		&stepProvision{},
+		&stepOrkaRestartBeforeCommit{},
+		&stepCreateImage{},
+	}
+
+	b.runner = &multistep.BasicRunner{Steps: steps}
+
+	stopTrapping := trapInterrupts(ui, b.Cancel)
+	defer stopTrapping()
+
+	b.runner.Run(ctx, state)
+
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, fmt.Errorf("build was cancelled")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, fmt.Errorf("build was halted")
+	}
+
+	artifact := &Artifact{
+		ImageName:      b.config.ImageName,
+		BuilderIdValue: BuilderId,
+		StateData:      map[string]interface{}{},
+	}
+
+	if imageState, ok := state.GetOk("artifact_state"); ok {
+		artifact.StateData = imageState.(map[string]interface{})
+	}
+
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		b.runner.Cancel()
+	}
+}
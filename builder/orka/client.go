@@ -0,0 +1,404 @@
+package orka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxRetries is used when a Config does not set max_retries.
+const DefaultMaxRetries = 5
+
+// DefaultRetryInterval is used when a Config does not set retry_interval.
+const DefaultRetryInterval = 2 * time.Second
+
+// DefaultHTTPTimeout bounds a single request/response round trip, not
+// counting retries.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// DefaultImageOperationTimeout bounds how long we wait for an image
+// save/commit to finish, used when a Config does not set
+// image_operation_timeout.
+const DefaultImageOperationTimeout = 30 * time.Minute
+
+// DefaultImageOperationPollInterval is used when a Config does not set
+// image_operation_poll_interval.
+const DefaultImageOperationPollInterval = 15 * time.Second
+
+// DefaultVMOperationTimeout bounds how long we wait for a VM stop/start and
+// subsequent SSH readiness, used when a Config does not set
+// vm_operation_timeout.
+const DefaultVMOperationTimeout = 5 * time.Minute
+
+// DefaultVMOperationPollInterval is used when a Config does not set
+// vm_operation_poll_interval.
+const DefaultVMOperationPollInterval = 5 * time.Second
+
+// APIError is returned by Client methods when the Orka API responds with
+// a non-2xx status. It carries the response body so callers can surface
+// the real reason instead of just the HTTP status line.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s [%s %s -> %s] %s", OrkaAPIResponseErrorMessage, e.Method, e.Path, e.Status, e.Body)
+}
+
+// Client is a structured client for the Orka API. It centralises auth,
+// retries and error handling so the builder's steps can stay thin
+// orchestrators.
+type Client struct {
+	Endpoint      string
+	HTTPClient    *http.Client
+	MaxRetries    int
+	RetryInterval time.Duration
+
+	username string
+	password string
+	token    string
+}
+
+// NewClient builds a Client for the given Orka endpoint.
+func NewClient(endpoint string, httpTimeout time.Duration, maxRetries int, retryInterval time.Duration) *Client {
+	if httpTimeout <= 0 {
+		httpTimeout = DefaultHTTPTimeout
+	}
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	if retryInterval <= 0 {
+		retryInterval = DefaultRetryInterval
+	}
+
+	return &Client{
+		Endpoint:      endpoint,
+		HTTPClient:    &http.Client{Timeout: httpTimeout},
+		MaxRetries:    maxRetries,
+		RetryInterval: retryInterval,
+	}
+}
+
+// Login authenticates against the Orka API and stores the bearer token on
+// the client for subsequent requests. The credentials are kept on the
+// client so a later 401 can trigger a transparent re-login.
+func (c *Client) Login(ctx context.Context, user, password string) (string, error) {
+	c.username = user
+	c.password = password
+	return c.reauthenticate(ctx)
+}
+
+// reauthenticate re-runs the login call with the credentials captured by
+// Login, refreshing c.token. It's what the retry loop in do calls when the
+// API reports the current token as expired.
+func (c *Client) reauthenticate(ctx context.Context) (string, error) {
+	var resp TokenLoginResponse
+	err := c.do(ctx, http.MethodPost, "token", &TokenLoginRequest{Email: c.username, Password: c.password}, &resp, false)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = resp.Token
+	return c.token, nil
+}
+
+// ListImages returns the images visible to the authenticated user, for
+// filter-based source image resolution.
+func (c *Client) ListImages(ctx context.Context) ([]Image, error) {
+	var resp ImageListResponse
+	if err := c.do(ctx, http.MethodGet, "resources/image/list", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Images, nil
+}
+
+// ImageStatus returns the current status of the named image.
+func (c *Client) ImageStatus(ctx context.Context, image string) (*ImageStatusResponse, error) {
+	var resp ImageStatusResponse
+	path := fmt.Sprintf("resources/image/status?image=%s", url.QueryEscape(image))
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AwaitImageReady polls the named image's status until it reaches the
+// terminal "ready" or "failed" state, the deadline elapses, or ctx is
+// cancelled. progress, if non-nil, is called after every poll so the caller
+// can report liveness to the user.
+func (c *Client) AwaitImageReady(ctx context.Context, image string, timeout, pollInterval time.Duration, progress func(status string)) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		resp, err := c.ImageStatus(ctx, image)
+		if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(resp.Status)
+		}
+
+		switch resp.Status {
+		case "ready":
+			return nil
+		case "failed":
+			return fmt.Errorf("image %s entered failed state: %s", image, resp.Message)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for image %s to become ready", timeout, image)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// CreateVMConfig creates a new builder VM configuration.
+func (c *Client) CreateVMConfig(ctx context.Context, req *VMCreateRequest) (*VMCreateResponse, error) {
+	var resp VMCreateResponse
+	if err := c.do(ctx, http.MethodPost, "resources/vm/create", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeployVM deploys a VM from a previously created configuration.
+func (c *Client) DeployVM(ctx context.Context, req *VMDeployRequest) (*VMDeployResponse, error) {
+	var resp VMDeployResponse
+	if err := c.do(ctx, http.MethodPost, "resources/vm/deploy", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SetVMMetadata attaches base64-encoded user data to a deployed VM so it is
+// available to the guest on first boot.
+func (c *Client) SetVMMetadata(ctx context.Context, req *VMMetadataRequest) (*VMMetadataResponse, error) {
+	var resp VMMetadataResponse
+	if err := c.do(ctx, http.MethodPost, "resources/vm/metadata", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StopVM stops a deployed VM.
+func (c *Client) StopVM(ctx context.Context, req *VMStopRequest) (*VMStopResponse, error) {
+	var resp VMStopResponse
+	if err := c.do(ctx, http.MethodPost, "resources/vm/stop", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StartVM starts a previously stopped VM.
+func (c *Client) StartVM(ctx context.Context, req *VMStartRequest) (*VMStartResponse, error) {
+	var resp VMStartResponse
+	if err := c.do(ctx, http.MethodPost, "resources/vm/start", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// VMStatus returns the current status of the given VM.
+func (c *Client) VMStatus(ctx context.Context, vmid string) (*VMStatusResponse, error) {
+	var resp VMStatusResponse
+	path := fmt.Sprintf("resources/vm/status?vm_id=%s", url.QueryEscape(vmid))
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AwaitVMStatus polls the given VM's status until it reaches target, an
+// "error" status is reported, the deadline elapses, or ctx is cancelled.
+// progress, if non-nil, is called after every poll.
+func (c *Client) AwaitVMStatus(ctx context.Context, vmid, target string, timeout, pollInterval time.Duration, progress func(status string)) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		resp, err := c.VMStatus(ctx, vmid)
+		if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(resp.Status)
+		}
+
+		if resp.Status == target {
+			return nil
+		}
+
+		if resp.Status == "error" {
+			return fmt.Errorf("vm %s entered error state: %s", vmid, resp.Message)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for vm %s to reach status %s", timeout, vmid, target)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// CommitImage commits the current VM disk back onto its source image.
+func (c *Client) CommitImage(ctx context.Context, req *ImageCommitRequest) (*ImageCommitResponse, error) {
+	var resp ImageCommitResponse
+	if err := c.do(ctx, http.MethodPost, "resources/image/commit", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SaveImage saves the VM's current disk as a new base image.
+func (c *Client) SaveImage(ctx context.Context, req *ImageSaveRequest) (*ImageSaveResponse, error) {
+	var resp ImageSaveResponse
+	if err := c.do(ctx, http.MethodPost, "resources/image/save", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CopyImage copies an existing image under a new name.
+func (c *Client) CopyImage(ctx context.Context, req *ImageCopyRequest) (*ImageCopyResponse, error) {
+	var resp ImageCopyResponse
+	if err := c.do(ctx, http.MethodPost, "resources/image/copy", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteImage removes an image from the cluster.
+func (c *Client) DeleteImage(ctx context.Context, req *ImageDeleteRequest) (*ImageDeleteResponse, error) {
+	var resp ImageDeleteResponse
+	if err := c.do(ctx, http.MethodDelete, "resources/image/delete", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// PurgeVM removes a VM and its configuration from the cluster.
+func (c *Client) PurgeVM(ctx context.Context, req *VMPurgeRequest) (*VMPurgeResponse, error) {
+	var resp VMPurgeResponse
+	if err := c.do(ctx, http.MethodDelete, "resources/vm/purge", req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do marshals reqBody, sends it to path with retries on transient failures,
+// and unmarshals the response into out. When auth is true and the API
+// reports the current token as expired (401), it re-logs in once and
+// retries the same attempt; if the token is rejected again (or the re-login
+// itself fails) the 401 is treated like any other transient failure and
+// retried against max_retries/retry_interval.
+func (c *Client) do(ctx context.Context, method, path string, reqBody interface{}, out interface{}, auth bool) error {
+	var reqBodyJSON []byte
+	if reqBody != nil {
+		var err error
+		reqBodyJSON, err = json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("%s: unable to encode request body: %s", OrkaAPIRequestErrorMessage, err)
+		}
+	}
+
+	reauthed := false
+
+	var lastErr error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.backoff(attempt)):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s", c.Endpoint, path), bytes.NewReader(reqBodyJSON))
+		if err != nil {
+			return fmt.Errorf("%s: %s", OrkaAPIRequestErrorMessage, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if auth {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %s", OrkaAPIRequestErrorMessage, err)
+			continue
+		}
+
+		respBodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("%s: unable to read response body: %s", OrkaAPIRequestErrorMessage, err)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && auth {
+			if !reauthed {
+				reauthed = true
+				if _, reauthErr := c.reauthenticate(ctx); reauthErr == nil {
+					// Retry the same attempt with the refreshed token; this
+					// doesn't count against max_retries since it's not a
+					// transient failure, just a stale credential.
+					attempt--
+					continue
+				}
+			}
+
+			lastErr = &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBodyBytes)}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			lastErr = &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBodyBytes)}
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(respBodyBytes)}
+		}
+
+		if out != nil && len(respBodyBytes) > 0 {
+			if err := json.Unmarshal(respBodyBytes, out); err != nil {
+				return fmt.Errorf("%s: unable to decode response body: %s", OrkaAPIRequestErrorMessage, err)
+			}
+		}
+
+		return nil
+	}
+
+	return lastErr
+}
+
+// backoff returns the exponential backoff delay for the given attempt
+// number (1-indexed), capped so a misconfigured retry_interval can't stall
+// a build indefinitely.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.RetryInterval * time.Duration(1<<uint(attempt-1))
+	max := 30 * time.Second
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,104 @@
+package orka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientDoRetriesOnServerError(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(VMCreateResponse{Message: "ok"})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, time.Second, 5, time.Millisecond)
+
+	var resp VMCreateResponse
+	if err := c.do(context.Background(), http.MethodPost, "resources/vm/create", &VMCreateRequest{}, &resp, false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls before success, got %d", calls)
+	}
+
+	if resp.Message != "ok" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestClientDoGivesUpAfterMaxRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, time.Second, 2, time.Millisecond)
+
+	err := c.do(context.Background(), http.MethodPost, "resources/vm/create", &VMCreateRequest{}, nil, false)
+	if err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+}
+
+func TestClientDoReauthsOnUnauthorized(t *testing.T) {
+	var tokenCalls, vmCalls int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenCalls++
+			json.NewEncoder(w).Encode(TokenLoginResponse{Token: "fresh-token"})
+		case "/resources/vm/create":
+			vmCalls++
+			if r.Header.Get("Authorization") != "Bearer fresh-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(VMCreateResponse{Message: "ok"})
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, time.Second, 5, time.Millisecond)
+	c.username = "user"
+	c.password = "pass"
+	c.token = "stale-token"
+
+	var resp VMCreateResponse
+	if err := c.do(context.Background(), http.MethodPost, "resources/vm/create", &VMCreateRequest{}, &resp, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if tokenCalls != 1 {
+		t.Fatalf("expected exactly one re-login, got %d", tokenCalls)
+	}
+
+	if vmCalls != 2 {
+		t.Fatalf("expected the request to be retried once after reauth, got %d calls", vmCalls)
+	}
+}
+
+func TestClientBackoffCapsDelay(t *testing.T) {
+	c := &Client{RetryInterval: time.Second}
+
+	if got := c.backoff(10); got != 30*time.Second {
+		t.Fatalf("expected backoff to cap at 30s, got %s", got)
+	}
+
+	if got := c.backoff(1); got != time.Second {
+		t.Fatalf("expected first backoff to equal retry_interval, got %s", got)
+	}
+}
@@ -0,0 +1,196 @@
+package orka
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// Config is the configuration structure for the Orka builder, decoded
+// from the Packer template.
+type Config struct {
+	packer.PackerConfig `mapstructure:",squash"`
+	Comm                communicator.Config `mapstructure:",squash"`
+
+	// Orka API connection details.
+	OrkaEndpoint string `mapstructure:"orka_endpoint"`
+	OrkaUser     string `mapstructure:"orka_user"`
+	OrkaPassword string `mapstructure:"orka_password"`
+
+	// Source and destination image configuration. Exactly one of SourceImage
+	// or SourceImageFilter should be set; the filter is resolved to a concrete
+	// image name at the start of the build.
+	SourceImage       string             `mapstructure:"source_image"`
+	SourceImageFilter *SourceImageFilter `mapstructure:"source_image_filter"`
+	ImageName         string             `mapstructure:"image_name"`
+
+	// Builder VM configuration.
+	OrkaVMBuilderName string `mapstructure:"orka_vm_name"`
+	OrkaVMCPUCore     int    `mapstructure:"orka_cpu_core"`
+
+	// Behaviour toggles.
+	ImagePrecopy       bool `mapstructure:"image_precopy"`
+	NoCreateImage      bool `mapstructure:"no_create_image"`
+	NoDeleteVM         bool `mapstructure:"do_not_delete"`
+	RestartBeforeImage bool `mapstructure:"restart_before_image"`
+
+	// Bootstrap data handed to the builder VM before the communicator
+	// connects. Only one of UserData or UserDataFile may be set.
+	UserData     string `mapstructure:"user_data"`
+	UserDataFile string `mapstructure:"user_data_file"`
+
+	// HTTP client tuning for the Orka API client.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	MaxRetries     int           `mapstructure:"max_retries"`
+	RetryInterval  time.Duration `mapstructure:"retry_interval"`
+
+	// Polling for the (potentially long-running) image save/commit operation.
+	ImageOperationTimeout      time.Duration `mapstructure:"image_operation_timeout"`
+	ImageOperationPollInterval time.Duration `mapstructure:"image_operation_poll_interval"`
+
+	// Polling for the VM stop/start cycle performed before a commit when
+	// restart_before_image is set.
+	VMOperationTimeout      time.Duration `mapstructure:"vm_operation_timeout"`
+	VMOperationPollInterval time.Duration `mapstructure:"vm_operation_poll_interval"`
+
+	ctx interpolate.Context
+}
+
+// SourceImageFilter selects a source image by predicate instead of by exact
+// name, resolved to a single matching image at the start of the build.
+type SourceImageFilter struct {
+	// NamePrefix matches images whose name starts with this value.
+	NamePrefix string `mapstructure:"name_prefix"`
+	// NameRegex matches images whose name satisfies this regular expression.
+	// Takes precedence over NamePrefix when both are set.
+	NameRegex string `mapstructure:"name_regex"`
+	// Owner restricts matches to images owned by this Orka user.
+	Owner string `mapstructure:"owner"`
+	// Tag restricts matches to images carrying this tag.
+	Tag string `mapstructure:"tag"`
+	// MinDate restricts matches to images published/modified on or after
+	// this RFC3339 timestamp.
+	MinDate string `mapstructure:"min_date"`
+	// MostRecent picks the newest matching image by modified/published
+	// timestamp when more than one image satisfies the filter. Without it,
+	// more than one match is treated as an ambiguous filter and errors out.
+	MostRecent bool `mapstructure:"most_recent"`
+}
+
+// NewConfig decodes the given raw template data into a Config, validating
+// the required fields and applying defaults.
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	c := new(Config)
+
+	err := config.Decode(c, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs *packer.MultiError
+
+	if c.OrkaEndpoint == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("orka_endpoint is required"))
+	}
+
+	if c.OrkaUser == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("orka_user is required"))
+	}
+
+	if c.OrkaPassword == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("orka_password is required"))
+	}
+
+	if c.SourceImage == "" && c.SourceImageFilter == nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("one of source_image or source_image_filter is required"))
+	}
+
+	if c.SourceImageFilter != nil {
+		if c.SourceImageFilter.NamePrefix == "" && c.SourceImageFilter.NameRegex == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("source_image_filter requires name_prefix or name_regex"))
+		}
+
+		if c.SourceImageFilter.NameRegex != "" {
+			if _, err := regexp.Compile(c.SourceImageFilter.NameRegex); err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid source_image_filter.name_regex: %s", err))
+			}
+		}
+
+		if c.SourceImageFilter.MinDate != "" {
+			if _, err := time.Parse(time.RFC3339, c.SourceImageFilter.MinDate); err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid source_image_filter.min_date: %s", err))
+			}
+		}
+	}
+
+	if c.ImageName == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("image_name is required"))
+	}
+
+	if c.OrkaVMBuilderName == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("orka_vm_name is required"))
+	}
+
+	if c.OrkaVMCPUCore == 0 {
+		c.OrkaVMCPUCore = 3
+	}
+
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = DefaultHTTPTimeout
+	}
+
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+
+	if c.RetryInterval == 0 {
+		c.RetryInterval = DefaultRetryInterval
+	}
+
+	if c.ImageOperationTimeout == 0 {
+		c.ImageOperationTimeout = DefaultImageOperationTimeout
+	}
+
+	if c.ImageOperationPollInterval == 0 {
+		c.ImageOperationPollInterval = DefaultImageOperationPollInterval
+	}
+
+	if c.VMOperationTimeout == 0 {
+		c.VMOperationTimeout = DefaultVMOperationTimeout
+	}
+
+	if c.VMOperationPollInterval == 0 {
+		c.VMOperationPollInterval = DefaultVMOperationPollInterval
+	}
+
+	if c.UserData != "" && c.UserDataFile != "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("only one of user_data or user_data_file can be set"))
+	} else if c.UserDataFile != "" {
+		contents, err := ioutil.ReadFile(c.UserDataFile)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("unable to read user_data_file: %s", err))
+		} else {
+			rendered, err := interpolate.Render(string(contents), &c.ctx)
+			if err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("unable to interpolate user_data_file: %s", err))
+			} else {
+				c.UserData = rendered
+			}
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, nil, errs
+	}
+
+	return c, nil, nil
+}
@@ -0,0 +1,94 @@
+package orka
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// resolveSourceImage lists the images visible to client and returns the name
+// of the one matching filter. When more than one image matches, filter.MostRecent
+// must be set, in which case the newest image (by modified, falling back to
+// published) wins.
+func resolveSourceImage(ctx context.Context, client *Client, filter *SourceImageFilter) (string, error) {
+	images, err := client.ListImages(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: unable to list images: %s", OrkaAPIRequestErrorMessage, err)
+	}
+
+	var nameRegex *regexp.Regexp
+	if filter.NameRegex != "" {
+		nameRegex, err = regexp.Compile(filter.NameRegex)
+		if err != nil {
+			return "", fmt.Errorf("invalid source_image_filter.name_regex: %s", err)
+		}
+	}
+
+	var minDate time.Time
+	if filter.MinDate != "" {
+		minDate, err = time.Parse(time.RFC3339, filter.MinDate)
+		if err != nil {
+			return "", fmt.Errorf("invalid source_image_filter.min_date: %s", err)
+		}
+	}
+
+	var matches []Image
+	for _, image := range images {
+		if nameRegex != nil {
+			if !nameRegex.MatchString(image.Name) {
+				continue
+			}
+		} else if filter.NamePrefix != "" && !strings.HasPrefix(image.Name, filter.NamePrefix) {
+			continue
+		}
+
+		if filter.Owner != "" && image.Owner != filter.Owner {
+			continue
+		}
+
+		if filter.Tag != "" && image.Tag != filter.Tag {
+			continue
+		}
+
+		if !minDate.IsZero() {
+			published, err := imageTimestamp(image)
+			if err != nil || published.Before(minDate) {
+				continue
+			}
+		}
+
+		matches = append(matches, image)
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no images matched source_image_filter")
+	}
+
+	if len(matches) == 1 {
+		return matches[0].Name, nil
+	}
+
+	if !filter.MostRecent {
+		return "", fmt.Errorf("source_image_filter matched %d images; set most_recent to pick the newest", len(matches))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		ti, _ := imageTimestamp(matches[i])
+		tj, _ := imageTimestamp(matches[j])
+		return ti.After(tj)
+	})
+
+	return matches[0].Name, nil
+}
+
+// imageTimestamp returns the best available timestamp for an image, preferring
+// Modified over Published since Orka updates Modified on every re-save.
+func imageTimestamp(image Image) (time.Time, error) {
+	if image.Modified != "" {
+		return time.Parse(time.RFC3339, image.Modified)
+	}
+	return time.Parse(time.RFC3339, image.Published)
+}
@@ -0,0 +1,99 @@
+package orka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClientWithImages(t *testing.T, images []Image) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ImageListResponse{Images: images})
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL, time.Second, 1, time.Millisecond)
+}
+
+func TestResolveSourceImageMostRecentByModified(t *testing.T) {
+	client := newTestClientWithImages(t, []Image{
+		{Name: "base-10", Modified: "2026-01-01T00:00:00Z"},
+		{Name: "base-11", Modified: "2026-02-01T00:00:00Z"},
+		{Name: "base-12", Modified: "2026-01-15T00:00:00Z"},
+	})
+
+	filter := &SourceImageFilter{NamePrefix: "base-", MostRecent: true}
+
+	name, err := resolveSourceImage(context.Background(), client, filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name != "base-11" {
+		t.Fatalf("expected newest image base-11, got %s", name)
+	}
+}
+
+func TestResolveSourceImageFallsBackToPublished(t *testing.T) {
+	client := newTestClientWithImages(t, []Image{
+		{Name: "base-old", Published: "2026-01-01T00:00:00Z"},
+		{Name: "base-new", Published: "2026-03-01T00:00:00Z"},
+	})
+
+	filter := &SourceImageFilter{NamePrefix: "base-", MostRecent: true}
+
+	name, err := resolveSourceImage(context.Background(), client, filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name != "base-new" {
+		t.Fatalf("expected newest image base-new, got %s", name)
+	}
+}
+
+func TestResolveSourceImageAmbiguousWithoutMostRecent(t *testing.T) {
+	client := newTestClientWithImages(t, []Image{
+		{Name: "base-10", Modified: "2026-01-01T00:00:00Z"},
+		{Name: "base-11", Modified: "2026-02-01T00:00:00Z"},
+	})
+
+	filter := &SourceImageFilter{NamePrefix: "base-"}
+
+	if _, err := resolveSourceImage(context.Background(), client, filter); err == nil {
+		t.Fatal("expected an error for an ambiguous filter without most_recent")
+	}
+}
+
+func TestResolveSourceImageNoMatches(t *testing.T) {
+	client := newTestClientWithImages(t, []Image{{Name: "other"}})
+
+	filter := &SourceImageFilter{NamePrefix: "base-"}
+
+	if _, err := resolveSourceImage(context.Background(), client, filter); err == nil {
+		t.Fatal("expected an error when no images match the filter")
+	}
+}
+
+func TestResolveSourceImageRegexTakesPrecedenceOverPrefix(t *testing.T) {
+	client := newTestClientWithImages(t, []Image{
+		{Name: "base-macos", Modified: "2026-01-01T00:00:00Z"},
+		{Name: "base-linux", Modified: "2026-01-01T00:00:00Z"},
+	})
+
+	filter := &SourceImageFilter{NamePrefix: "base-", NameRegex: "^base-macos$"}
+
+	name, err := resolveSourceImage(context.Background(), client, filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name != "base-macos" {
+		t.Fatalf("expected base-macos, got %s", name)
+	}
+}
@@ -0,0 +1,64 @@
+package orka
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// forceQuitThreshold is the number of interrupts, received within
+// forceQuitWindow of the first of them, after which we give up on orderly cleanup
+// and exit immediately. This gives operators a way to recover a wedged
+// daemon without leaving the process un-killable.
+const forceQuitThreshold = 3
+
+// forceQuitWindow bounds how long after the first interrupt repeated
+// interrupts still count toward forceQuitThreshold; an interrupt arriving
+// later starts a new window.
+const forceQuitWindow = 5 * time.Second
+
+// trapInterrupts starts a goroutine that watches for SIGINT/SIGTERM. The
+// first signal calls cancel (triggering the multistep runner's normal
+// cleanup, i.e. purging the builder VM and any pre-copied image); repeated
+// signals within forceQuitWindow of the first escalate to an immediate,
+// uncleaned exit.
+// It returns a function that stops watching for signals.
+func trapInterrupts(ui packer.Ui, cancel func()) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		var count int
+		var windowStart time.Time
+
+		for range sigCh {
+			now := time.Now()
+			if count == 0 || now.Sub(windowStart) > forceQuitWindow {
+				count = 0
+				windowStart = now
+			}
+			count++
+
+			if count == 1 {
+				ui.Say(fmt.Sprintf("Interrupt received: cleaning up the builder VM. Press Ctrl-C %d more times within %s to force-quit without cleanup.", forceQuitThreshold-1, forceQuitWindow))
+				cancel()
+				continue
+			}
+
+			if count >= forceQuitThreshold {
+				log.Printf("Received %d interrupts: skipping cleanup and exiting immediately", count)
+				os.Exit(1)
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(sigCh)
+	}
+}
@@ -1,13 +1,8 @@
 package orka
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"time"
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
@@ -21,68 +16,15 @@ func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
 	vmid := state.Get("vmid").(string)
-	token := state.Get("token").(string)
+	client := state.Get("client").(*Client)
 
 	if config.NoCreateImage {
 		ui.Say("Skipping image creation because of 'no_create_image' being set.")
 		return multistep.ActionContinue
 	}
 
-	// HTTP Client.
-
-	client := &http.Client{
-		Timeout: time.Minute * 5,
-	}
-
 	ui.Say(fmt.Sprintf("Image creation is using VM ID [%s]", vmid))
 	ui.Say(fmt.Sprintf("Image name is [%s]", config.ImageName))
-
-	// ui.Say("We must stop and then start (restart) the VM first")
-
-	// stopVMRequestData := VMStopRequest{vmid}
-	// stopVMRequestDataJSON, _ := json.Marshal(stopVMRequestData)
-	// vmStopRequest, err := http.NewRequest(
-	// 	http.MethodPost,
-	// 	fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/vm/stop"),
-	// 	bytes.NewBuffer(stopVMRequestDataJSON),
-	// )
-	// vmStopRequest.Header.Set("Content-Type", "application/json")
-	// vmStopRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	// ui.Say("Stopping and waiting 10 seconds...")
-	// vmStopResponse, err := client.Do(vmStopRequest)
-	// if err != nil {
-	// 	state.Put("error", err)
-	// 	return multistep.ActionHalt
-	// }
-	// var vmStopResponseData VMStopResponse
-	// vmStopRespBytes, _ := ioutil.ReadAll(vmStopResponse.Body)
-	// json.Unmarshal(vmStopRespBytes, &vmStopResponseData)
-	// vmStopResponse.Body.Close()
-	// time.Sleep(time.Second * 10)
-
-	// startVMRequestData := VMStartRequest{vmid}
-	// startVMRequestDataJSON, _ := json.Marshal(startVMRequestData)
-	// vmStartRequest, err := http.NewRequest(
-	// 	http.MethodPost,
-	// 	fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/vm/start"),
-	// 	bytes.NewBuffer(startVMRequestDataJSON),
-	// )
-	// vmStartRequest.Header.Set("Content-Type", "application/json")
-	// vmStartRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	// ui.Say("Starting and waiting 30 seconds...")
-	// vmStartResponse, err := client.Do(vmStartRequest)
-	// if err != nil {
-	// 	ui.Error(fmt.Errorf("Error while starting VM %s: %s", vmid, err).Error())
-	// 	return multistep.ActionHalt
-	// }
-	// var vmStartResponseData VMStartResponse
-	// vmStartResponseBytes, _ := ioutil.ReadAll(vmStartResponse.Body)
-	// json.Unmarshal(vmStartResponseBytes, &vmStartResponseData)
-	// vmStartRequest.Body.Close()
-	// time.Sleep(time.Second * 30)
-
-	// Now that the VM is stopped, we can commit or save it.
-
 	ui.Say("Please wait as this can take a little while...")
 
 	if config.ImagePrecopy {
@@ -90,68 +32,62 @@ func (s *stepCreateImage) Run(ctx context.Context, state multistep.StateBag) mul
 
 		ui.Say("Committing existing image since pre-copy is being used")
 
-		imageCommitRequestData := ImageCommitRequest{vmid}
-		imageCommitRequestDataJSON, _ := json.Marshal(imageCommitRequestData)
-		imageCommitRequest, err := http.NewRequest(
-			http.MethodPost,
-			fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/image/commit"),
-			bytes.NewBuffer(imageCommitRequestDataJSON),
-		)
-		imageCommitRequest.Header.Set("Content-Type", "application/json")
-		imageCommitRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		imageCommitResponse, err := client.Do(imageCommitRequest)
+		resp, err := client.CommitImage(ctx, &ImageCommitRequest{VMId: vmid})
 
 		if err != nil {
-			e := fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err)
-			ui.Error(e.Error())
+			ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+			state.Put("error", err)
+			s.failed = true
 			return multistep.ActionHalt
 		}
 
-		var imageCommitResponseData ImageCommitResponse
-		imageCommitResponseBytes, _ := ioutil.ReadAll(imageCommitResponse.Body)
-		json.Unmarshal(imageCommitResponseBytes, &imageCommitResponseData)
-		imageCommitResponse.Body.Close()
-
-		if imageCommitResponse.StatusCode != 200 {
-			ui.Error(fmt.Errorf("Error committing image [%s]", imageCommitResponse.Status).Error())
-		} else {
-			ui.Say(fmt.Sprintf("Image comitted [%s] [%s]", imageCommitResponse.Status, imageCommitResponseData.Message))
-		}
+		ui.Say(fmt.Sprintf("Image committed [%s]", resp.Message))
 	} else {
 		// By default we use the save endpoint to generate a new base image from
 		// the running VM's current image.
 
 		ui.Say(fmt.Sprintf("Saving new image [%s]", config.ImageName))
 
-		imageSaveRequestData := ImageSaveRequest{vmid, config.ImageName}
-		imageSaveRequestDataJSON, _ := json.Marshal(imageSaveRequestData)
-		imageSaveRequest, err := http.NewRequest(
-			http.MethodPost,
-			fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/image/save"),
-			bytes.NewBuffer(imageSaveRequestDataJSON),
-		)
-		imageSaveRequest.Header.Set("Content-Type", "application/json")
-		imageSaveRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		imageSaveResponse, err := client.Do(imageSaveRequest)
+		resp, err := client.SaveImage(ctx, &ImageSaveRequest{VMId: vmid, Image: config.ImageName})
 
 		if err != nil {
 			ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+			state.Put("error", err)
+			s.failed = true
 			return multistep.ActionHalt
 		}
 
-		var imageSaveResponseData ImageSaveResponse
-		imageSaveResponseBytes, _ := ioutil.ReadAll(imageSaveResponse.Body)
-		json.Unmarshal(imageSaveResponseBytes, &imageSaveResponseData)
-		imageSaveResponse.Body.Close()
+		ui.Say(fmt.Sprintf("Image saved [%s]", resp.Message))
+	}
 
-		if imageSaveResponse.StatusCode != 200 {
-			ui.Error(fmt.Errorf("%s [%s]", OrkaAPIResponseErrorMessage, imageSaveResponse.Status).Error())
-			return multistep.ActionHalt
-		}
+	ui.Say(fmt.Sprintf("Waiting for image [%s] to become ready (timeout %s)", config.ImageName, config.ImageOperationTimeout))
+
+	err := client.AwaitImageReady(ctx, config.ImageName, config.ImageOperationTimeout, config.ImageOperationPollInterval, func(status string) {
+		ui.Say(fmt.Sprintf("Image [%s] status: %s", config.ImageName, status))
+	})
 
-		ui.Say(fmt.Sprintf("Image saved [%s] [%s]", imageSaveResponse.Status, imageSaveResponseData.Message))
+	if err != nil {
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+		state.Put("error", err)
+		s.failed = true
+		return multistep.ActionHalt
 	}
 
+	ui.Say(fmt.Sprintf("Image [%s] is ready", config.ImageName))
+
+	sourceImage, _ := state.Get("source_image").(string)
+
+	state.Put("artifact_state", map[string]interface{}{
+		"orka.image.name":      config.ImageName,
+		"orka.image.id":        config.ImageName,
+		"orka.image.source":    sourceImage,
+		"orka.image.precopied": config.ImagePrecopy,
+		"orka.vm.builder_name": config.OrkaVMBuilderName,
+		"orka.vm.cpu_cores":    config.OrkaVMCPUCore,
+		"orka.endpoint":        config.OrkaEndpoint,
+		"orka.type":            BuilderId,
+	})
+
 	return multistep.ActionContinue
 }
 
@@ -1,12 +1,9 @@
 package orka
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strconv"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -18,50 +15,20 @@ type stepOrkaCreate struct {
 	precopyFailed bool
 }
 
-func (s *stepOrkaCreate) createOrkaToken(state multistep.StateBag) (string, error) {
-	config := state.Get("config").(*Config)
-	user := config.OrkaUser
-	password := config.OrkaPassword
-
-	// HTTP Client.
-	client := &http.Client{}
-
-	reqData := TokenLoginRequest{user, password}
-	reqDataJSON, _ := json.Marshal(reqData)
-	req, err := http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s/%s", config.OrkaEndpoint, "token"),
-		bytes.NewBuffer(reqDataJSON),
-	)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-
-	if err != nil {
-		e := fmt.Errorf("Error while logging into the Orka API: %s", err)
-		return "", e
-	}
-
-	var respData TokenLoginResponse
-	respBodyBytes, _ := ioutil.ReadAll(resp.Body)
-	json.Unmarshal(respBodyBytes, &respData)
-	resp.Body.Close()
-
-	return respData.Token, nil
-}
-
 func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
 
+	client := NewClient(config.OrkaEndpoint, config.RequestTimeout, config.MaxRetries, config.RetryInterval)
+	state.Put("client", client)
+
 	// ############################
 	// # ORKA API LOGIN FOR TOKEN #
 	// ############################
 
 	ui.Say("Logging into Orka API endpoint")
 
-	token, err := s.createOrkaToken(state)
-
-	if err != nil {
+	if _, err := client.Login(ctx, config.OrkaUser, config.OrkaPassword); err != nil {
 		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
 		state.Put("error", err)
 		s.failed = true
@@ -70,37 +37,42 @@ func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) mult
 
 	ui.Say("Logged in with token")
 
-	// Store the token in the data bag for cleanup later.
-	// I am not sure how long these tokens actually last in Orka by default, but I would
-	// assume as the build doesn't take hours and hours, it should still be valid by then.
-	state.Put("token", token)
+	// Resolve source_image_filter (if set) to a concrete image name before
+	// anything below uses the source image.
 
-	// HTTP Client.
-	client := &http.Client{}
+	sourceImage := config.SourceImage
+
+	if config.SourceImageFilter != nil {
+		ui.Say("Resolving source_image_filter to an image")
+
+		resolved, err := resolveSourceImage(ctx, client, config.SourceImageFilter)
+		if err != nil {
+			ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+			state.Put("error", err)
+			s.failed = true
+			return multistep.ActionHalt
+		}
+
+		ui.Say(fmt.Sprintf("Resolved source_image_filter to image [%s]", resolved))
+		sourceImage = resolved
+	}
+
+	state.Put("source_image", sourceImage)
 
 	// Builder VM launch image is always the source image. If pre-copy is enabled,
 	// however, it will get replaced with the pre-copied destination image instead
 	// (below)
 
-	actualImage := config.SourceImage
+	actualImage := sourceImage
 
 	if config.ImagePrecopy {
 		if config.NoCreateImage {
 			ui.Say("Skipping source image pre-copy because of 'no_create_image' being set")
 		} else {
-			ui.Say(fmt.Sprintf("Pre-copying source image [%s] to destination image [%s]", config.SourceImage, config.ImageName))
+			ui.Say(fmt.Sprintf("Pre-copying source image [%s] to destination image [%s]", sourceImage, config.ImageName))
 			ui.Say("This can take awhile depending on how big the source image is - please wait...")
 
-			imageCopyRequestData := ImageCopyRequest{config.SourceImage, config.ImageName}
-			imageCopyRequestDataJSON, _ := json.Marshal(imageCopyRequestData)
-			imageCopyRequest, err := http.NewRequest(
-				http.MethodPost,
-				fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/image/copy"),
-				bytes.NewBuffer(imageCopyRequestDataJSON),
-			)
-			imageCopyRequest.Header.Set("Content-Type", "application/json")
-			imageCopyRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-			imageCopyResponse, err := client.Do(imageCopyRequest)
+			_, err := client.CopyImage(ctx, &ImageCopyRequest{Image: sourceImage, NewImage: config.ImageName})
 
 			if err != nil {
 				ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
@@ -110,19 +82,6 @@ func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) mult
 				return multistep.ActionHalt
 			}
 
-			var imageCopyResponseData ImageCopyResponse
-			imageCopyResponseBytes, _ := ioutil.ReadAll(imageCopyResponse.Body)
-			json.Unmarshal(imageCopyResponseBytes, &imageCopyResponseData)
-			imageCopyResponse.Body.Close()
-
-			if imageCopyResponse.StatusCode != 200 {
-				e := fmt.Errorf("Error from API: %s", imageCopyResponse.Status)
-				ui.Error(e.Error())
-				state.Put("error", e)
-				s.failed = true
-				return multistep.ActionHalt
-			}
-
 			ui.Say("Image copied")
 			ui.Say(fmt.Sprintf("Builder VM configuration will use pre-copied base image %s",
 				actualImage))
@@ -144,43 +103,47 @@ func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) mult
 
 	ui.Say(fmt.Sprintf("Creating a Builder VM configuration [%s]",
 		config.OrkaVMBuilderName))
-	vmCreateConfigRequestData := VMCreateRequest{
+
+	_, err := client.CreateVMConfig(ctx, &VMCreateRequest{
 		OrkaVMName:  config.OrkaVMBuilderName,
 		OrkaVMImage: actualImage,
 		OrkaImage:   config.OrkaVMBuilderName,
 		OrkaCPUCore: config.OrkaVMCPUCore,
 		VCPUCount:   config.OrkaVMCPUCore,
-	}
-	vmCreateConfigRequestDataJSON, _ := json.Marshal(vmCreateConfigRequestData)
-	vmCreateConfigRequest, err := http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/vm/create"),
-		bytes.NewBuffer(vmCreateConfigRequestDataJSON),
-	)
-	vmCreateConfigRequest.Header.Set("Content-Type", "application/json")
-	vmCreateConfigRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	vmCreateConfigResponse, err := client.Do(vmCreateConfigRequest)
+	})
 
 	if err != nil {
-		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
-		return multistep.ActionHalt
-	}
-
-	var vmCreateConfigResponseData VMCreateResponse
-	vmCreateConfigResponseBytes, _ := ioutil.ReadAll(vmCreateConfigResponse.Body)
-	json.Unmarshal(vmCreateConfigResponseBytes, &vmCreateConfigResponseData)
-	vmCreateConfigResponse.Body.Close()
-
-	if vmCreateConfigResponse.StatusCode != 201 {
-		e := fmt.Errorf("%s [%s]", OrkaAPIResponseErrorMessage, vmCreateConfigResponse.Status)
-		ui.Error(e.Error())
-		state.Put("error", e)
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIResponseErrorMessage, err).Error())
+		state.Put("error", err)
 		s.failed = true
 		return multistep.ActionHalt
 	}
 
 	ui.Say(fmt.Sprintf("Created builder VM configuration [%s]", config.OrkaVMBuilderName))
 
+	// ##########################
+	// # ATTACH BOOTSTRAP DATA  #
+	// ##########################
+
+	// This has to happen before the VM is deployed: the guest only reads its
+	// metadata on first boot, so attaching it to an already-running VM would
+	// be too late for the use cases (SSH keys, MDM enrollment, Xcode select)
+	// this is meant to unblock.
+
+	if config.UserData != "" {
+		ui.Say("Attaching user_data to the builder VM configuration")
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(config.UserData))
+		_, err := client.SetVMMetadata(ctx, &VMMetadataRequest{OrkaVMName: config.OrkaVMBuilderName, Metadata: encoded})
+
+		if err != nil {
+			ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+			state.Put("error", err)
+			s.failed = true
+			return multistep.ActionHalt
+		}
+	}
+
 	// #################
 	// # DEPLOY THE VM #
 	// #################
@@ -189,26 +152,11 @@ func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) mult
 
 	ui.Say(fmt.Sprintf("Creating builder VM based on [%s] configuration", config.OrkaVMBuilderName))
 
-	vmDeployRequestData := VMDeployRequest{config.OrkaVMBuilderName}
-	vmDeployRequestDataJSON, _ := json.Marshal(vmDeployRequestData)
-	vmDeployRequest, err := http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/vm/deploy"),
-		bytes.NewBuffer(vmDeployRequestDataJSON),
-	)
-	vmDeployRequest.Header.Set("Content-Type", "application/json")
-	vmDeployRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	vmDeployResponse, err := client.Do(vmDeployRequest)
-	var vmDeployResponseData VMDeployResponse
-	vmDeployResponseBodyBytes, _ := ioutil.ReadAll(vmDeployResponse.Body)
-	json.Unmarshal(vmDeployResponseBodyBytes, &vmDeployResponseData)
-	vmDeployResponse.Body.Close()
-
-	if vmDeployResponse.StatusCode != 200 {
-		state.Put(
-			"error",
-			fmt.Errorf("Error from API while deploying Orka VM: %s",
-				vmDeployResponse.Status))
+	vmDeployResponseData, err := client.DeployVM(ctx, &VMDeployRequest{OrkaVMName: config.OrkaVMBuilderName})
+
+	if err != nil {
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+		state.Put("error", err)
 		s.failed = true
 		return multistep.ActionHalt
 	}
@@ -239,20 +187,9 @@ func (s *stepOrkaCreate) Run(ctx context.Context, state multistep.StateBag) mult
 func (s *stepOrkaCreate) precopyImageDelete(state multistep.StateBag) error {
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
-	token := state.Get("token").(string)
-
-	client := &http.Client{}
+	client := state.Get("client").(*Client)
 
-	imageDeleteRequestData := ImageDeleteRequest{config.OrkaVMBuilderName}
-	imageDeleteRequestDataJSON, _ := json.Marshal(imageDeleteRequestData)
-	imageDeleteRequest, err := http.NewRequest(
-		http.MethodDelete,
-		fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/image/delete"),
-		bytes.NewBuffer(imageDeleteRequestDataJSON),
-	)
-	imageDeleteRequest.Header.Set("Content-Type", "application/json")
-	imageDeleteRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	imageDeleteResponse, err := client.Do(imageDeleteRequest)
+	_, err := client.DeleteImage(context.Background(), &ImageDeleteRequest{Image: config.ImageName})
 
 	if err != nil {
 		e := fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err)
@@ -261,14 +198,7 @@ func (s *stepOrkaCreate) precopyImageDelete(state multistep.StateBag) error {
 		return e
 	}
 
-	if imageDeleteResponse.StatusCode != 200 {
-		e := fmt.Errorf("Image could not be deleted [%s]", imageDeleteResponse.Status)
-		ui.Error(e.Error())
-		return e
-	}
-
-	ui.Say(fmt.Sprintf("Image deleted [%s]", imageDeleteResponse.Status))
-	imageDeleteResponse.Body.Close()
+	ui.Say(fmt.Sprintf("Image deleted [%s]", config.ImageName))
 
 	return nil
 }
@@ -276,7 +206,7 @@ func (s *stepOrkaCreate) precopyImageDelete(state multistep.StateBag) error {
 func (s *stepOrkaCreate) Cleanup(state multistep.StateBag) {
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
-	token := state.Get("token").(string)
+	client := state.Get("client").(*Client)
 
 	if config.NoDeleteVM {
 		ui.Say("We are skipping the deletion of the builder VM and its configuration because of do_not_delete being set.")
@@ -303,31 +233,20 @@ func (s *stepOrkaCreate) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	// vmid := state.Get("vmid").(string)
-
 	ui.Say("Removing builder VM and its configuration...")
 
-	client := &http.Client{}
-	vmPurgeRequestData := VMPurgeRequest{config.OrkaVMBuilderName}
-	vmPurgeRequestDatJSON, _ := json.Marshal(vmPurgeRequestData)
-	vmPurgeRequest, err := http.NewRequest(
-		http.MethodDelete,
-		fmt.Sprintf("%s/%s", config.OrkaEndpoint, "resources/vm/purge"),
-		bytes.NewBuffer(vmPurgeRequestDatJSON),
-	)
-	vmPurgeRequest.Header.Set("Content-Type", "application/json")
-	vmPurgeRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	vmPurgeResponse, err := client.Do(vmPurgeRequest)
+	_, err := client.PurgeVM(context.Background(), &VMPurgeRequest{OrkaVMName: config.OrkaVMBuilderName})
 
 	if err != nil {
-		e := fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err)
-		ui.Error(e.Error())
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
 		state.Put("error", err)
+		return
 	}
 
-	if vmPurgeResponse.StatusCode != 200 {
-		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIResponseErrorMessage, vmPurgeResponse.Status).Error())
-	} else {
-		ui.Say("Builder VM and configuration purged")
+	ui.Say("Builder VM and configuration purged")
+
+	if _, cancelled := state.GetOk(multistep.StateCancelled); cancelled && config.ImagePrecopy && !config.NoCreateImage {
+		ui.Say(fmt.Sprintf("Build was cancelled: cleaning up pre-copied image %s", config.ImageName))
+		s.precopyImageDelete(state)
 	}
 }
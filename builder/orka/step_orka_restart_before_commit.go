@@ -0,0 +1,113 @@
+package orka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepOrkaRestartBeforeCommit stops and restarts the builder VM before it is
+// committed/saved into an image, gated by restart_before_image. Some base
+// images only flush disk state cleanly across a reboot, so committing a VM
+// that was never restarted can produce an image that won't boot.
+type stepOrkaRestartBeforeCommit struct {
+	failed bool
+}
+
+func (s *stepOrkaRestartBeforeCommit) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	if !config.RestartBeforeImage {
+		return multistep.ActionContinue
+	}
+
+	vmid := state.Get("vmid").(string)
+	client := state.Get("client").(*Client)
+
+	ui.Say("Stopping builder VM before commit")
+
+	if _, err := client.StopVM(ctx, &VMStopRequest{VMId: vmid}); err != nil {
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+		state.Put("error", err)
+		s.failed = true
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Waiting for builder VM to stop")
+
+	err := client.AwaitVMStatus(ctx, vmid, "stopped", config.VMOperationTimeout, config.VMOperationPollInterval, func(status string) {
+		ui.Say(fmt.Sprintf("VM [%s] status: %s", vmid, status))
+	})
+
+	if err != nil {
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+		state.Put("error", err)
+		s.failed = true
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Starting builder VM")
+
+	if _, err := client.StartVM(ctx, &VMStartRequest{VMId: vmid}); err != nil {
+		ui.Error(fmt.Errorf("%s [%s]", OrkaAPIRequestErrorMessage, err).Error())
+		state.Put("error", err)
+		s.failed = true
+		return multistep.ActionHalt
+	}
+
+	sshHost := state.Get("ssh_host").(string)
+	sshPort := state.Get("ssh_port").(int)
+
+	ui.Say(fmt.Sprintf("Waiting for SSH to become reachable at [%s:%d]", sshHost, sshPort))
+
+	if err := waitForTCP(ctx, sshHost, sshPort, config.VMOperationTimeout, config.VMOperationPollInterval); err != nil {
+		ui.Error(fmt.Errorf("Error while waiting for builder VM to come back up: %s", err).Error())
+		state.Put("error", err)
+		s.failed = true
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Builder VM restarted and reachable over SSH")
+
+	return multistep.ActionContinue
+}
+
+func (s *stepOrkaRestartBeforeCommit) Cleanup(state multistep.StateBag) {}
+
+// waitForTCP dials address host:port with backoff until a connection
+// succeeds, the deadline elapses, or ctx is cancelled. The wait between
+// dials starts at pollInterval and doubles after each failed dial, capped at
+// the time left until the deadline.
+func waitForTCP(ctx context.Context, host string, port int, timeout, pollInterval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	address := fmt.Sprintf("%s:%d", host, port)
+	delay := pollInterval
+
+	for {
+		conn, err := net.DialTimeout("tcp", address, pollInterval)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s to accept connections", timeout, address)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if remaining := time.Until(deadline); delay > remaining {
+			delay = remaining
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package orka
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// stepProvision runs the template's provisioners against the builder VM.
+// It has to run after communicator.StepConnectSSH (which puts a live
+// packer.Communicator in the state bag) and before the image is
+// stopped/started/committed, or the resulting image would just be an
+// unmodified copy of the source image.
+type stepProvision struct{}
+
+func (s *stepProvision) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	comm := state.Get("communicator").(packer.Communicator)
+	hook := state.Get("hook").(packer.Hook)
+	ui := state.Get("ui").(packer.Ui)
+
+	if err := hook.Run(ctx, packer.HookProvision, ui, comm, nil); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepProvision) Cleanup(multistep.StateBag) {}
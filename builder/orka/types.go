@@ -0,0 +1,159 @@
+package orka
+
+// Error messages shared across the Orka API calls.
+const (
+	OrkaAPIRequestErrorMessage  = "Error while making a request to the Orka API"
+	OrkaAPIResponseErrorMessage = "Error response from the Orka API"
+)
+
+// TokenLoginRequest is the payload for POST token.
+type TokenLoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// TokenLoginResponse is the response from POST token.
+type TokenLoginResponse struct {
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+// VMCreateRequest is the payload for POST resources/vm/create.
+type VMCreateRequest struct {
+	OrkaVMName  string `json:"orka_vm_name"`
+	OrkaVMImage string `json:"orka_base_image"`
+	OrkaImage   string `json:"orka_image"`
+	OrkaCPUCore int    `json:"orka_cpu_core"`
+	VCPUCount   int    `json:"vcpu_count"`
+}
+
+// VMCreateResponse is the response from POST resources/vm/create.
+type VMCreateResponse struct {
+	Message string `json:"message"`
+}
+
+// VMDeployRequest is the payload for POST resources/vm/deploy.
+type VMDeployRequest struct {
+	OrkaVMName string `json:"orka_vm_name"`
+}
+
+// VMDeployResponse is the response from POST resources/vm/deploy.
+type VMDeployResponse struct {
+	VMId    string `json:"vm_id"`
+	IP      string `json:"ip"`
+	SSHPort string `json:"ssh_port"`
+	Message string `json:"message"`
+}
+
+// VMStopRequest is the payload for POST resources/vm/stop.
+type VMStopRequest struct {
+	VMId string `json:"vm_id"`
+}
+
+// VMStopResponse is the response from POST resources/vm/stop.
+type VMStopResponse struct {
+	Message string `json:"message"`
+}
+
+// VMStartRequest is the payload for POST resources/vm/start.
+type VMStartRequest struct {
+	VMId string `json:"vm_id"`
+}
+
+// VMStartResponse is the response from POST resources/vm/start.
+type VMStartResponse struct {
+	Message string `json:"message"`
+}
+
+// VMPurgeRequest is the payload for DELETE resources/vm/purge.
+type VMPurgeRequest struct {
+	OrkaVMName string `json:"orka_vm_name"`
+}
+
+// VMPurgeResponse is the response from DELETE resources/vm/purge.
+type VMPurgeResponse struct {
+	Message string `json:"message"`
+}
+
+// VMStatusResponse is the response from GET resources/vm/status. Status is
+// one of "deploying", "started", "stopped", "stopping" or "error".
+type VMStatusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// ImageCopyRequest is the payload for POST resources/image/copy.
+type ImageCopyRequest struct {
+	Image    string `json:"image"`
+	NewImage string `json:"new_image"`
+}
+
+// ImageCopyResponse is the response from POST resources/image/copy.
+type ImageCopyResponse struct {
+	Message string `json:"message"`
+}
+
+// ImageCommitRequest is the payload for POST resources/image/commit.
+type ImageCommitRequest struct {
+	VMId string `json:"vm_id"`
+}
+
+// ImageCommitResponse is the response from POST resources/image/commit.
+type ImageCommitResponse struct {
+	Message string `json:"message"`
+}
+
+// ImageSaveRequest is the payload for POST resources/image/save.
+type ImageSaveRequest struct {
+	VMId  string `json:"vm_id"`
+	Image string `json:"image"`
+}
+
+// ImageSaveResponse is the response from POST resources/image/save.
+type ImageSaveResponse struct {
+	Message string `json:"message"`
+}
+
+// ImageDeleteRequest is the payload for DELETE resources/image/delete.
+type ImageDeleteRequest struct {
+	Image string `json:"image"`
+}
+
+// ImageDeleteResponse is the response from DELETE resources/image/delete.
+type ImageDeleteResponse struct {
+	Message string `json:"message"`
+}
+
+// VMMetadataRequest is the payload for POST resources/vm/metadata.
+type VMMetadataRequest struct {
+	OrkaVMName string `json:"orka_vm_name"`
+	Metadata   string `json:"metadata"`
+}
+
+// VMMetadataResponse is the response from POST resources/vm/metadata.
+type VMMetadataResponse struct {
+	Message string `json:"message"`
+}
+
+// Image describes a single image as returned by GET resources/image/list.
+type Image struct {
+	Name      string `json:"name"`
+	Owner     string `json:"owner"`
+	Tag       string `json:"tag"`
+	Status    string `json:"status"`
+	Published string `json:"published"`
+	Modified  string `json:"modified"`
+}
+
+// ImageStatusResponse is the response from GET resources/image/status.
+// Status is one of "pending", "ready" or "failed".
+type ImageStatusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// ImageListResponse is the response from GET resources/image/list.
+type ImageListResponse struct {
+	Images  []Image `json:"images"`
+	Message string  `json:"message"`
+}